@@ -0,0 +1,58 @@
+package config
+
+// Argument is a single --build-arg key/value pair passed to the builder.
+type Argument struct {
+	Key   string
+	Value string
+}
+
+// ImageConfig describes how to obtain or build a single image, including
+// any images it depends on.
+type ImageConfig struct {
+	ImageName    string
+	Dependencies []string
+	Arguments    []Argument
+	NoCache      bool
+	ForcePull    bool
+	ForceRebuild bool
+
+	// Builder selects the build backend: "buildkit" (default),
+	// "classic", or "buildah". See pkg/image.Builder.
+	Builder string
+
+	// Podman is set when the target daemon was detected to be a Podman
+	// rootful/rootless socket rather than a real Docker daemon. Podman's
+	// compat /build endpoint doesn't understand BuildKit's Version or
+	// SessionID fields, so the buildkit builder falls back to the
+	// classic, session-less build path when this is set.
+	Podman bool
+
+	// Context is the build context: a local directory, a git:// or
+	// https://...git URL, an http(s) tarball URL, a local .tar/.tar.gz
+	// file, or "-" to read a pre-packed tar stream from stdin.
+	Context    string
+	Dockerfile string
+
+	// CacheFrom lists registry refs (or "type=registry,ref=..."
+	// exporter strings) to seed the build cache from.
+	CacheFrom []string
+
+	// InlineCache embeds cache metadata in the built image itself
+	// (BUILDKIT_INLINE_CACHE=1), so a later `cache-from` of the image
+	// can reuse its layers without a separate cache exporter.
+	InlineCache bool
+
+	// CacheTo is a BuildKit cache exporter spec, e.g.
+	// "type=registry,ref=..." or "type=local,dest=...".
+	CacheTo string
+
+	// Outputs are additional BuildKit exporter specs for the build
+	// result itself, e.g. "type=local,dest=./out" or "type=oci,dest=image.tar".
+	Outputs []string
+}
+
+// LoadImage loads the named image config from the project's dodo
+// configuration.
+func LoadImage(name string) (*ImageConfig, error) {
+	return &ImageConfig{ImageName: name}, nil
+}
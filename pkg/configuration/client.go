@@ -0,0 +1,10 @@
+package configuration
+
+// ClientOptions configures how dodo connects to the container daemon.
+type ClientOptions struct {
+	Host     string
+	Version  string
+	CAFile   string
+	CertFile string
+	KeyFile  string
+}
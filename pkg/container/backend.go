@@ -0,0 +1,104 @@
+package container
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/docker/docker/client"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+)
+
+// libpodAPIVersion is the compat API version prefix libpod mounts its
+// native endpoints under, e.g. /v4.0.0/libpod/containers/<id>/stop.
+const libpodAPIVersion = "v4.0.0"
+
+// Backend identifies which daemon a Container is actually talking to.
+// Podman's compat API accepts almost everything Docker's does, but a
+// handful of endpoints and build options only make sense on one or the
+// other, so callers branch on this where it matters (e.g. Stop).
+type Backend string
+
+const (
+	BackendDocker Backend = "docker"
+	BackendPodman Backend = "podman"
+)
+
+// DetectBackend pings the daemon and inspects the response headers
+// Podman adds to its compat endpoints (Libpod-API-Version,
+// Libpod-Buildah-Version) to tell it apart from a real Docker daemon.
+// It is exported so pkg/image can make the same determination before
+// picking a build backend.
+func DetectBackend(ctx context.Context, dockerClient *client.Client) Backend {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://docker/_ping", nil)
+	if err != nil {
+		return BackendDocker
+	}
+
+	resp, err := dockerClient.HTTPClient().Do(req)
+	if err != nil {
+		return BackendDocker
+	}
+	defer resp.Body.Close()
+
+	if resp.Header.Get("Libpod-API-Version") != "" || resp.Header.Get("Libpod-Buildah-Version") != "" {
+		return BackendPodman
+	}
+	return BackendDocker
+}
+
+// stopLibpod stops and removes a container through libpod's native
+// endpoints instead of the docker-compat ones, because libpod's inspect
+// after the stop reports the real exit code (the docker-compat shim on
+// Podman reports it as 0 regardless of how the container exited).
+func (c *Container) stopLibpod(ctx context.Context) error {
+	base := fmt.Sprintf("http://docker/%s/libpod/containers/%s", libpodAPIVersion, c.name)
+
+	if err := libpodRequest(ctx, c.client, http.MethodPost, base+"/stop"); err != nil {
+		return err
+	}
+
+	var inspect struct {
+		State struct {
+			ExitCode int
+		}
+	}
+	if err := libpodRequestJSON(ctx, c.client, http.MethodGet, base+"/json", &inspect); err == nil && inspect.State.ExitCode != 0 {
+		log.Warnf("container %s exited with code %d", c.name, inspect.State.ExitCode)
+	}
+
+	return libpodRequest(ctx, c.client, http.MethodDelete, base)
+}
+
+func libpodRequest(ctx context.Context, dockerClient *client.Client, method string, url string) error {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := dockerClient.HTTPClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("libpod request %s %s failed: %s", method, url, resp.Status)
+	}
+	return nil
+}
+
+func libpodRequestJSON(ctx context.Context, dockerClient *client.Client, method string, url string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := dockerClient.HTTPClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("libpod request %s %s failed: %s", method, url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/docker/docker/client"
 	"github.com/docker/docker/pkg/stringid"
 	"github.com/docker/docker/pkg/term"
+	"github.com/oclaussen/dodo/pkg/configuration"
 	"github.com/oclaussen/dodo/pkg/types"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/net/context"
@@ -22,12 +23,12 @@ type Container struct {
 	daemon      bool
 	config      *types.Backdrop
 	client      *client.Client
-	context     context.Context
+	backend     Backend
 	tmpPath     string
 	authConfigs map[string]dockerapi.AuthConfig
 }
 
-func NewContainer(config *types.Backdrop, authConfigs map[string]dockerapi.AuthConfig, daemon bool) (*Container, error) {
+func NewContainer(ctx context.Context, config *types.Backdrop, authConfigs map[string]dockerapi.AuthConfig, daemon bool) (*Container, error) {
 	dockerClient, err := getDockerClient()
 	if err != nil {
 		return nil, err
@@ -45,40 +46,44 @@ func NewContainer(config *types.Backdrop, authConfigs map[string]dockerapi.AuthC
 		daemon:      daemon,
 		config:      config,
 		client:      dockerClient,
-		context:     context.Background(),
+		backend:     DetectBackend(ctx, dockerClient),
 		tmpPath:     fmt.Sprintf("/tmp/dodo-%s/", stringid.GenerateRandomID()[:20]),
 		authConfigs: authConfigs,
 	}, nil
 }
 
-func (c *Container) Run() error {
-	imageId, err := c.GetImage()
+func (c *Container) Run(ctx context.Context) error {
+	imageId, err := c.GetImage(ctx)
 	if err != nil {
 		return err
 	}
 
-	containerID, err := c.create(imageId)
+	containerID, err := c.create(ctx, imageId)
 	if err != nil {
 		return err
 	}
 
 	if c.daemon {
 		return c.client.ContainerStart(
-			c.context,
+			ctx,
 			containerID,
 			dockerapi.ContainerStartOptions{},
 		)
 	} else {
-		return c.run(containerID, hasTTY())
+		return c.run(ctx, containerID, hasTTY())
 	}
 }
 
-func (c *Container) Stop() error {
-	if err := c.client.ContainerStop(c.context, c.name, nil); err != nil {
+func (c *Container) Stop(ctx context.Context) error {
+	if c.backend == BackendPodman {
+		return c.stopLibpod(ctx)
+	}
+
+	if err := c.client.ContainerStop(ctx, c.name, nil); err != nil {
 		return err
 	}
 
-	if err := c.client.ContainerRemove(c.context, c.name, dockerapi.ContainerRemoveOptions{}); err != nil {
+	if err := c.client.ContainerRemove(ctx, c.name, dockerapi.ContainerRemoveOptions{}); err != nil {
 		return err
 	}
 
@@ -93,7 +98,7 @@ func hasTTY() bool {
 
 func getDockerClient() (*client.Client, error) {
 	opts := &configuration.ClientOptions{}
-	mutators := []client.Opt{}
+	var mutators []client.Opt
 	if len(opts.Version) > 0 {
 		mutators = append(mutators, client.WithVersion(opts.Version))
 	} else {
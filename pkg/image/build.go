@@ -2,44 +2,76 @@ package image
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 	"net"
 	"os"
 
-	"github.com/containerd/console"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/pkg/jsonmessage"
-	"github.com/docker/docker/pkg/stringid"
 	"github.com/docker/docker/pkg/term"
-	controlapi "github.com/moby/buildkit/api/services/control"
 	"github.com/moby/buildkit/client"
-	"github.com/moby/buildkit/util/appcontext"
-	"github.com/moby/buildkit/util/progress/progressui"
 	"github.com/oclaussen/dodo/pkg/config"
+	"github.com/oclaussen/dodo/pkg/progress"
 	"golang.org/x/net/context"
 	"golang.org/x/sync/errgroup"
 )
 
-func (image *Image) Get() (string, error) {
+func (image *Image) Get(ctx context.Context) (string, error) {
+	sink, err := image.newSink(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer sink.Close()
+
+	return image.get(ctx, sink)
+}
+
+func (image *Image) Build(ctx context.Context) (string, error) {
+	sink, err := image.newSink(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer sink.Close()
+
+	return image.build(ctx, sink)
+}
+
+// newSink creates the single progress.Sink that a build and all of its
+// dependency builds/pulls render into, so they share one TUI instead of
+// each dependency spinning up its own terminal renderer.
+func (image *Image) newSink(ctx context.Context) (progress.Sink, error) {
+	_, stdErrIsTerminal := term.GetFdInfo(os.Stderr)
+	switch {
+	case image.config.ForceRebuild && stdErrIsTerminal:
+		return progress.NewTerminalSink(ctx, os.Stderr)
+	case image.config.ForceRebuild:
+		return progress.NewStreamSink(os.Stderr), nil
+	default:
+		return progress.NewStreamSink(io.Discard), nil
+	}
+}
+
+func (image *Image) get(ctx context.Context, sink progress.Sink) (string, error) {
 	if image.config.ForceRebuild || len(image.config.ImageName) == 0 {
-		return image.Build()
+		return image.build(ctx, sink)
 	}
 
 	imgs, err := image.client.ImageList(
-		context.Background(),
+		ctx,
 		types.ImageListOptions{
 			Filters: filters.NewArgs(filters.Arg("reference", image.config.ImageName)),
 		},
 	)
 	if err != nil || len(imgs) == 0 {
-		return image.Build()
+		return image.build(ctx, sink)
 	}
 
 	return imgs[0].ID, nil
 }
 
-func (image *Image) Build() (string, error) {
+func (image *Image) build(ctx context.Context, sink progress.Sink) (string, error) {
 	for _, name := range image.config.Dependencies {
 		// TODO: refactor here, the dependency on config is uncomfortable
 		conf, err := config.LoadImage(name)
@@ -49,11 +81,11 @@ func (image *Image) Build() (string, error) {
 		if image.config.ForceRebuild {
 			conf.ForceRebuild = true
 		}
-		dependency, err := NewImage(image.client, image.authConfigs, conf)
+		dependency, err := NewImage(ctx, image.client, image.authConfigs, conf)
 		if err != nil {
 			return "", err
 		}
-		if _, err := dependency.Get(); err != nil {
+		if _, err := dependency.get(ctx, sink); err != nil {
 			return "", err
 		}
 	}
@@ -65,37 +97,28 @@ func (image *Image) Build() (string, error) {
 	defer contextData.cleanup()
 
 	imageID := ""
-	displayCh := make(chan *client.SolveStatus)
-	_, stdErrIsTerminal := term.GetFdInfo(os.Stderr)
 
-	eg, _ := errgroup.WithContext(appcontext.Context())
+	eg, egCtx := errgroup.WithContext(ctx)
 
-	eg.Go(func() error {
-		return image.session.Run(
-			context.TODO(),
-			func(ctx context.Context, proto string, meta map[string][]string) (net.Conn, error) {
-				return image.client.DialHijack(ctx, "/session", proto, meta)
-			},
-		)
-	})
+	builder := selectBuilder(image.config)
 
-	if image.config.ForceRebuild && stdErrIsTerminal {
+	// Only the BuildKit backend uses the session; the classic and buildah
+	// backends must not depend on the daemon's /session endpoint.
+	if _, ok := builder.(*buildkitBuilder); ok {
 		eg.Go(func() error {
-			cons, err := console.ConsoleFromFile(os.Stderr)
-			if err != nil {
-				return err
-			}
-			return progressui.DisplaySolveStatus(context.TODO(), "", cons, os.Stderr, displayCh)
+			return image.session.Run(
+				egCtx,
+				func(ctx context.Context, proto string, meta map[string][]string) (net.Conn, error) {
+					return image.client.DialHijack(ctx, "/session", proto, meta)
+				},
+			)
 		})
 	}
 
 	eg.Go(func() error {
-		defer func() {
-			close(displayCh)
-			image.session.Close()
-		}()
+		defer image.session.Close()
 
-		imageID, err = image.runBuild(contextData, displayCh)
+		imageID, err = builder.build(egCtx, image, contextData, sink)
 		return err
 	})
 
@@ -111,46 +134,7 @@ func (image *Image) Build() (string, error) {
 	return imageID, nil
 }
 
-func (image *Image) runBuild(contextData *contextData, displayCh chan *client.SolveStatus) (string, error) {
-	args := map[string]*string{}
-	for _, arg := range image.config.Arguments {
-		args[arg.Key] = &arg.Value
-	}
-
-	var tags []string
-	if image.config.ImageName != "" {
-		tags = append(tags, image.config.ImageName)
-	}
-
-	response, err := image.client.ImageBuild(
-		context.Background(),
-		nil,
-		types.ImageBuildOptions{
-			Tags:           tags,
-			SuppressOutput: false,
-			NoCache:        image.config.NoCache,
-			Remove:         true,
-			ForceRemove:    true,
-			PullParent:     image.config.ForcePull,
-			Dockerfile:     contextData.dockerfileName,
-			BuildArgs:      args,
-			AuthConfigs:    image.authConfigs,
-			Version:        types.BuilderBuildKit,
-			RemoteContext:  contextData.remote,
-			SessionID:      image.session.ID(),
-			BuildID:        stringid.GenerateRandomID(),
-		},
-	)
-	if err != nil {
-		return "", err
-	}
-	defer response.Body.Close()
-
-	_, stdErrIsTerminal := term.GetFdInfo(os.Stderr)
-	return handleBuildResult(response.Body, displayCh, image.config.ForceRebuild && stdErrIsTerminal)
-}
-
-func handleBuildResult(response io.Reader, displayCh chan *client.SolveStatus, printOutput bool) (string, error) {
+func handleBuildResult(response io.Reader, sink progress.Sink, reportTrace bool) (string, error) {
 	var imageID string
 	decoder := json.NewDecoder(response)
 	for {
@@ -167,56 +151,28 @@ func handleBuildResult(response io.Reader, displayCh chan *client.SolveStatus, p
 		}
 
 		if msg.Aux != nil {
-			if msg.ID == "moby.image.id" {
+			switch {
+			// BuildKit reports the image ID under "moby.image.id", the
+			// classic builder under an empty aux ID.
+			case msg.ID == "moby.image.id" || msg.ID == "":
 				var result types.BuildResult
 				if err := json.Unmarshal(*msg.Aux, &result); err != nil {
 					continue
 				}
 				imageID = result.ID
-			} else if printOutput && msg.ID == "moby.buildkit.trace" {
-				var resp controlapi.StatusResponse
-				var dt []byte
-				if err := json.Unmarshal(*msg.Aux, &dt); err != nil {
-					continue
-				}
-				if err := (&resp).Unmarshal(dt); err != nil {
+			case reportTrace && msg.ID == "moby.buildkit.trace":
+				status, err := progress.DecodeTrace(msg.Aux)
+				if err != nil {
 					continue
 				}
-
-				s := client.SolveStatus{}
-				for _, v := range resp.Vertexes {
-					s.Vertexes = append(s.Vertexes, &client.Vertex{
-						Digest:    v.Digest,
-						Inputs:    v.Inputs,
-						Name:      v.Name,
-						Started:   v.Started,
-						Completed: v.Completed,
-						Error:     v.Error,
-						Cached:    v.Cached,
-					})
-				}
-				for _, v := range resp.Statuses {
-					s.Statuses = append(s.Statuses, &client.VertexStatus{
-						ID:        v.ID,
-						Vertex:    v.Vertex,
-						Name:      v.Name,
-						Total:     v.Total,
-						Current:   v.Current,
-						Timestamp: v.Timestamp,
-						Started:   v.Started,
-						Completed: v.Completed,
-					})
-				}
-				for _, v := range resp.Logs {
-					s.Logs = append(s.Logs, &client.VertexLog{
-						Vertex:    v.Vertex,
-						Stream:    int(v.Stream),
-						Data:      v.Msg,
-						Timestamp: v.Timestamp,
-					})
-				}
-
-				displayCh <- &s
+				sink.Write(status)
+			default:
+				// Cache-to and multi-output exporters report their
+				// results under their own aux IDs; forward them as a
+				// log line instead of dropping them silently.
+				sink.Write(&client.SolveStatus{
+					Logs: []*client.VertexLog{{Data: []byte(fmt.Sprintf("%s: %s\n", msg.ID, string(*msg.Aux)))}},
+				})
 			}
 		}
 	}
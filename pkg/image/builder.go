@@ -0,0 +1,256 @@
+package image
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/stringid"
+	"github.com/moby/buildkit/client"
+	"github.com/oclaussen/dodo/pkg/config"
+	"github.com/oclaussen/dodo/pkg/progress"
+	"golang.org/x/net/context"
+)
+
+// parseExporter splits an exporter spec like "type=registry,ref=..."
+// into its type and attributes. A bare ref with no "type=" returns an
+// empty type.
+func parseExporter(spec string) (string, map[string]string) {
+	attrs := map[string]string{}
+	typ := ""
+	for _, part := range strings.Split(spec, ",") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		if kv[0] == "type" {
+			typ = kv[1]
+			continue
+		}
+		attrs[kv[0]] = kv[1]
+	}
+	return typ, attrs
+}
+
+// exporterRef extracts the ref from a "type=registry,ref=..." exporter
+// spec for flags that take a plain image reference.
+func exporterRef(spec string) string {
+	typ, attrs := parseExporter(spec)
+	if typ == "" {
+		return spec
+	}
+	if ref, ok := attrs["ref"]; ok {
+		return ref
+	}
+	return spec
+}
+
+// Builder turns a prepared build context into an image ID.
+type Builder interface {
+	build(ctx context.Context, image *Image, contextData *contextData, sink progress.Sink) (string, error)
+}
+
+// Names accepted by the image config's Builder field.
+const (
+	BuilderBuildKit = "buildkit"
+	BuilderClassic  = "classic"
+	BuilderBuildah  = "buildah"
+)
+
+func selectBuilder(conf *config.ImageConfig) Builder {
+	switch conf.Builder {
+	case BuilderClassic:
+		return &classicBuilder{}
+	case BuilderBuildah:
+		return &buildahBuilder{}
+	}
+
+	if conf.Podman {
+		// Podman's compat /build endpoint rejects BuildKit's Version/SessionID fields.
+		return &classicBuilder{}
+	}
+
+	return &buildkitBuilder{}
+}
+
+// buildkitBuilder is the default backend, talking to a BuildKit-aware
+// daemon over the session established in Image.Build.
+type buildkitBuilder struct{}
+
+func (b *buildkitBuilder) build(ctx context.Context, image *Image, contextData *contextData, sink progress.Sink) (string, error) {
+	args := map[string]*string{}
+	for _, arg := range image.config.Arguments {
+		args[arg.Key] = &arg.Value
+	}
+
+	var tags []string
+	if image.config.ImageName != "" {
+		tags = append(tags, image.config.ImageName)
+	}
+
+	if image.config.InlineCache {
+		inlineCache := "1"
+		args["BUILDKIT_INLINE_CACHE"] = &inlineCache
+	}
+
+	var cacheFrom []string
+	for _, spec := range image.config.CacheFrom {
+		cacheFrom = append(cacheFrom, exporterRef(spec))
+	}
+
+	// Needs a docker/docker client recent enough to vendor
+	// ImageBuildOptions.Outputs (API >= 1.42).
+	var outputs []types.ImageBuildOutput
+	for _, spec := range image.config.Outputs {
+		typ, attrs := parseExporter(spec)
+		outputs = append(outputs, types.ImageBuildOutput{Type: typ, Attrs: attrs})
+	}
+	if image.config.CacheTo != "" {
+		typ, attrs := parseExporter(image.config.CacheTo)
+		attrs["type"] = typ
+		outputs = append(outputs, types.ImageBuildOutput{Type: "cache", Attrs: attrs})
+	}
+
+	// A remote context is fetched by the daemon itself; a local directory
+	// (packed by prepareLocalContext) or a pre-packed tar is sent as the
+	// request body.
+	var body io.Reader
+	if contextData.tarStream != nil {
+		body = contextData.tarStream
+	}
+
+	response, err := image.client.ImageBuild(
+		ctx,
+		body,
+		types.ImageBuildOptions{
+			Tags:           tags,
+			SuppressOutput: false,
+			NoCache:        image.config.NoCache,
+			Remove:         true,
+			ForceRemove:    true,
+			PullParent:     image.config.ForcePull,
+			Dockerfile:     contextData.dockerfileName,
+			BuildArgs:      args,
+			AuthConfigs:    image.authConfigs,
+			Version:        types.BuilderBuildKit,
+			RemoteContext:  contextData.remote,
+			SessionID:      image.session.ID(),
+			BuildID:        stringid.GenerateRandomID(),
+			CacheFrom:      cacheFrom,
+			Outputs:        outputs,
+		},
+	)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	return handleBuildResult(response.Body, sink, true)
+}
+
+// classicBuilder targets daemons too old to speak BuildKit.
+type classicBuilder struct{}
+
+func (b *classicBuilder) build(ctx context.Context, image *Image, contextData *contextData, sink progress.Sink) (string, error) {
+	args := map[string]*string{}
+	for _, arg := range image.config.Arguments {
+		args[arg.Key] = &arg.Value
+	}
+
+	var tags []string
+	if image.config.ImageName != "" {
+		tags = append(tags, image.config.ImageName)
+	}
+
+	response, err := image.client.ImageBuild(
+		ctx,
+		contextData.tarStream,
+		types.ImageBuildOptions{
+			Tags:           tags,
+			SuppressOutput: false,
+			NoCache:        image.config.NoCache,
+			Remove:         true,
+			ForceRemove:    true,
+			PullParent:     image.config.ForcePull,
+			Dockerfile:     contextData.dockerfileName,
+			BuildArgs:      args,
+			AuthConfigs:    image.authConfigs,
+			RemoteContext:  contextData.remote,
+		},
+	)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	return handleBuildResult(response.Body, sink, false)
+}
+
+// buildahBuilder shells out to `buildah bud` for daemonless builds.
+type buildahBuilder struct{}
+
+func (b *buildahBuilder) build(ctx context.Context, image *Image, contextData *contextData, sink progress.Sink) (string, error) {
+	buildArgs := []string{"bud", "--quiet"}
+	if image.config.ImageName != "" {
+		buildArgs = append(buildArgs, "-t", image.config.ImageName)
+	}
+	if image.config.NoCache {
+		buildArgs = append(buildArgs, "--no-cache")
+	}
+	if image.config.ForcePull {
+		buildArgs = append(buildArgs, "--pull-always")
+	}
+	for _, arg := range image.config.Arguments {
+		buildArgs = append(buildArgs, "--build-arg", fmt.Sprintf("%s=%s", arg.Key, arg.Value))
+	}
+	for _, spec := range image.config.CacheFrom {
+		buildArgs = append(buildArgs, "--cache-from", exporterRef(spec))
+	}
+	if image.config.CacheTo != "" {
+		buildArgs = append(buildArgs, "--cache-to", exporterRef(image.config.CacheTo))
+	}
+	for _, output := range image.config.Outputs {
+		buildArgs = append(buildArgs, "--output", output)
+	}
+	var buildContext string
+	switch {
+	case contextData.remote != "":
+		buildContext = contextData.remote
+	case contextData.tarStream != nil && contextData.contextDir == "":
+		// Pipe a packed tar into bud's stdin using "-" as the context.
+		cmd := exec.CommandContext(ctx, "buildah", append(buildArgs, "-f", contextData.dockerfileName, "-")...)
+		return runBuildahBud(cmd, contextData.tarStream, sink)
+	default:
+		buildContext = contextData.contextDir
+	}
+	buildArgs = append(buildArgs, "-f", contextData.dockerfileName, buildContext)
+
+	return runBuildahBud(exec.CommandContext(ctx, "buildah", buildArgs...), nil, sink)
+}
+
+func runBuildahBud(cmd *exec.Cmd, stdin io.Reader, sink progress.Sink) (string, error) {
+	var stdout bytes.Buffer
+	cmd.Stdin = stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = &sinkWriter{sink: sink}
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("buildah bud: %w", err)
+	}
+
+	return string(bytes.TrimSpace(stdout.Bytes())), nil
+}
+
+// sinkWriter adapts a progress.Sink to an io.Writer.
+type sinkWriter struct {
+	sink progress.Sink
+}
+
+func (w *sinkWriter) Write(p []byte) (int, error) {
+	w.sink.Write(&client.SolveStatus{
+		Logs: []*client.VertexLog{{Data: p}},
+	})
+	return len(p), nil
+}
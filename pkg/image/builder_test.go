@@ -0,0 +1,52 @@
+package image
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseExporter(t *testing.T) {
+	cases := []struct {
+		name      string
+		spec      string
+		wantType  string
+		wantAttrs map[string]string
+	}{
+		{"bare ref", "myimage:latest", "", map[string]string{}},
+		{"type and ref", "type=registry,ref=example.com/foo:cache", "registry", map[string]string{"ref": "example.com/foo:cache"}},
+		{"multiple attrs", "type=local,dest=./out,mode=max", "local", map[string]string{"dest": "./out", "mode": "max"}},
+		{"no ref attribute", "type=local,dest=./out", "local", map[string]string{"dest": "./out"}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			typ, attrs := parseExporter(c.spec)
+			if typ != c.wantType {
+				t.Errorf("type = %q, want %q", typ, c.wantType)
+			}
+			if !reflect.DeepEqual(attrs, c.wantAttrs) {
+				t.Errorf("attrs = %v, want %v", attrs, c.wantAttrs)
+			}
+		})
+	}
+}
+
+func TestExporterRef(t *testing.T) {
+	cases := []struct {
+		name string
+		spec string
+		want string
+	}{
+		{"bare ref passthrough", "myimage:latest", "myimage:latest"},
+		{"ref attribute extracted", "type=registry,ref=example.com/foo:cache", "example.com/foo:cache"},
+		{"no ref attribute falls back to spec", "type=local,dest=./out", "type=local,dest=./out"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := exporterRef(c.spec); got != c.want {
+				t.Errorf("exporterRef(%q) = %q, want %q", c.spec, got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package image
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/builder/remotecontext/urlutil"
+	"github.com/docker/docker/pkg/archive"
+	"github.com/moby/buildkit/session"
+	"github.com/oclaussen/dodo/pkg/config"
+)
+
+// contextData describes a build context resolved from an image config.
+type contextData struct {
+	// contextDir is the local build context directory, if any.
+	contextDir string
+
+	// dockerfileName is the Dockerfile path relative to the context.
+	dockerfileName string
+
+	// remote is a git:// or http(s) URL for the daemon to fetch itself.
+	remote string
+
+	// tarStream is the build context as a tar stream: stdin, a .tar/.tar.gz
+	// file, or a local directory packed by prepareLocalContext.
+	tarStream io.ReadCloser
+
+	cleanup func()
+}
+
+func prepareContext(conf *config.ImageConfig, sess *session.Session) (*contextData, error) {
+	dockerfile := conf.Dockerfile
+	if dockerfile == "" {
+		dockerfile = "Dockerfile"
+	}
+
+	contextDir := conf.Context
+	if contextDir == "" {
+		contextDir = "."
+	}
+
+	switch {
+	case urlutil.IsGitURL(contextDir):
+		return prepareRemoteContext(contextDir, dockerfile)
+	case urlutil.IsURL(contextDir):
+		return prepareRemoteContext(contextDir, dockerfile)
+	case contextDir == "-":
+		return prepareTarStreamContext(os.Stdin, dockerfile)
+	case strings.HasSuffix(contextDir, ".tar") || strings.HasSuffix(contextDir, ".tar.gz"):
+		file, err := os.Open(contextDir)
+		if err != nil {
+			return nil, err
+		}
+		return prepareTarStreamContext(file, dockerfile)
+	default:
+		return prepareLocalContext(contextDir, dockerfile)
+	}
+}
+
+// prepareRemoteContext points RemoteContext at a git:// or http(s) URL
+// and lets the daemon fetch it.
+func prepareRemoteContext(remote string, dockerfile string) (*contextData, error) {
+	return &contextData{
+		dockerfileName: filepath.Clean(dockerfile),
+		remote:         remote,
+		cleanup:        func() {},
+	}, nil
+}
+
+// prepareTarStreamContext uses a pre-packed tar stream as the build
+// context, read from stdin ("-") or a local .tar/.tar.gz file.
+func prepareTarStreamContext(stream io.ReadCloser, dockerfile string) (*contextData, error) {
+	return &contextData{
+		dockerfileName: filepath.Clean(dockerfile),
+		tarStream:      stream,
+		cleanup:        func() { stream.Close() },
+	}, nil
+}
+
+func prepareLocalContext(contextDir string, dockerfile string) (*contextData, error) {
+	tarStream, err := archive.Tar(contextDir, archive.Uncompressed)
+	if err != nil {
+		return nil, err
+	}
+
+	return &contextData{
+		contextDir:     contextDir,
+		dockerfileName: filepath.Clean(dockerfile),
+		tarStream:      tarStream,
+		cleanup:        func() { tarStream.Close() },
+	}, nil
+}
@@ -0,0 +1,57 @@
+package image
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/oclaussen/dodo/pkg/config"
+)
+
+func TestPrepareContextScheme(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "Dockerfile"), []byte("FROM scratch"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tarFile := filepath.Join(dir, "ctx.tar")
+	if err := os.WriteFile(tarFile, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name       string
+		contextDir string
+		wantRemote string
+		wantTar    bool
+		wantDir    string
+	}{
+		{"git url", "git://example.com/repo.git", "git://example.com/repo.git", false, ""},
+		{"http url", "https://example.com/context.tar", "https://example.com/context.tar", false, ""},
+		{"stdin", "-", "", true, ""},
+		{"tar file", tarFile, "", true, ""},
+		{"local dir", dir, "", true, dir},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			data, err := prepareContext(&config.ImageConfig{Context: c.contextDir}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.contextDir != "-" {
+				defer data.cleanup()
+			}
+
+			if data.remote != c.wantRemote {
+				t.Errorf("remote = %q, want %q", data.remote, c.wantRemote)
+			}
+			if (data.tarStream != nil) != c.wantTar {
+				t.Errorf("tarStream set = %v, want %v", data.tarStream != nil, c.wantTar)
+			}
+			if data.contextDir != c.wantDir {
+				t.Errorf("contextDir = %q, want %q", data.contextDir, c.wantDir)
+			}
+		})
+	}
+}
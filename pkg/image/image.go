@@ -0,0 +1,39 @@
+package image
+
+import (
+	"errors"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/moby/buildkit/session"
+	"github.com/oclaussen/dodo/pkg/config"
+	"github.com/oclaussen/dodo/pkg/container"
+	"golang.org/x/net/context"
+)
+
+var errMissingImageID = errors.New("image build did not return an image id")
+
+// Image knows how to obtain a built or pulled image ID for a single
+// image config, building any of its dependencies first.
+type Image struct {
+	config      *config.ImageConfig
+	client      *client.Client
+	session     *session.Session
+	authConfigs map[string]types.AuthConfig
+}
+
+func NewImage(ctx context.Context, dockerClient *client.Client, authConfigs map[string]types.AuthConfig, conf *config.ImageConfig) (*Image, error) {
+	sess, err := session.NewSession(conf.ImageName, "")
+	if err != nil {
+		return nil, err
+	}
+
+	conf.Podman = container.DetectBackend(ctx, dockerClient) == container.BackendPodman
+
+	return &Image{
+		config:      conf,
+		client:      dockerClient,
+		session:     sess,
+		authConfigs: authConfigs,
+	}, nil
+}
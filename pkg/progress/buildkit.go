@@ -0,0 +1,57 @@
+package progress
+
+import (
+	"encoding/json"
+
+	controlapi "github.com/moby/buildkit/api/services/control"
+	"github.com/moby/buildkit/client"
+)
+
+// DecodeTrace turns a "moby.buildkit.trace" aux message into a
+// client.SolveStatus.
+func DecodeTrace(aux *json.RawMessage) (*client.SolveStatus, error) {
+	var dt []byte
+	if err := json.Unmarshal(*aux, &dt); err != nil {
+		return nil, err
+	}
+
+	var resp controlapi.StatusResponse
+	if err := (&resp).Unmarshal(dt); err != nil {
+		return nil, err
+	}
+
+	s := &client.SolveStatus{}
+	for _, v := range resp.Vertexes {
+		s.Vertexes = append(s.Vertexes, &client.Vertex{
+			Digest:    v.Digest,
+			Inputs:    v.Inputs,
+			Name:      v.Name,
+			Started:   v.Started,
+			Completed: v.Completed,
+			Error:     v.Error,
+			Cached:    v.Cached,
+		})
+	}
+	for _, v := range resp.Statuses {
+		s.Statuses = append(s.Statuses, &client.VertexStatus{
+			ID:        v.ID,
+			Vertex:    v.Vertex,
+			Name:      v.Name,
+			Total:     v.Total,
+			Current:   v.Current,
+			Timestamp: v.Timestamp,
+			Started:   v.Started,
+			Completed: v.Completed,
+		})
+	}
+	for _, v := range resp.Logs {
+		s.Logs = append(s.Logs, &client.VertexLog{
+			Vertex:    v.Vertex,
+			Stream:    int(v.Stream),
+			Data:      v.Msg,
+			Timestamp: v.Timestamp,
+		})
+	}
+
+	return s, nil
+}
@@ -0,0 +1,92 @@
+// Package progress is a shared progress reporting abstraction for
+// image builds and pulls.
+package progress
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"github.com/containerd/console"
+	"github.com/moby/buildkit/client"
+	"github.com/moby/buildkit/util/progress/progressui"
+)
+
+// Sink receives build/pull progress as BuildKit solve-status vertices.
+type Sink interface {
+	Write(*client.SolveStatus)
+	Close()
+}
+
+// channelSink forwards every status onto a channel, e.g. for tests or
+// structured JSON output.
+type channelSink struct {
+	ch chan *client.SolveStatus
+}
+
+// NewChannelSink returns a Sink that forwards every status onto ch, and
+// the channel it writes to.
+func NewChannelSink() (Sink, chan *client.SolveStatus) {
+	ch := make(chan *client.SolveStatus)
+	return &channelSink{ch: ch}, ch
+}
+
+func (s *channelSink) Write(status *client.SolveStatus) { s.ch <- status }
+func (s *channelSink) Close()                           { close(s.ch) }
+
+// terminalSink renders progress with BuildKit's progressui, the same
+// renderer used for interactive `docker build`/`buildctl` output.
+type terminalSink struct {
+	ch   chan *client.SolveStatus
+	done chan struct{}
+}
+
+// NewTerminalSink starts rendering solve statuses to out using
+// progressui. out must be backed by a terminal.
+func NewTerminalSink(ctx context.Context, out *os.File) (Sink, error) {
+	cons, err := console.ConsoleFromFile(out)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan *client.SolveStatus)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		progressui.DisplaySolveStatus(ctx, "", cons, out, ch)
+	}()
+
+	return &terminalSink{ch: ch, done: done}, nil
+}
+
+func (s *terminalSink) Write(status *client.SolveStatus) { s.ch <- status }
+func (s *terminalSink) Close() {
+	close(s.ch)
+	<-s.done
+}
+
+// streamSink prints a plain, non-interactive line per completed vertex,
+// plus the raw log data, for non-terminal output (CI logs, redirected
+// stderr). Vertex status (progress) updates are not printed.
+type streamSink struct {
+	out io.Writer
+}
+
+// NewStreamSink returns a Sink that writes completed vertex names and
+// log data to out without any terminal control sequences.
+func NewStreamSink(out io.Writer) Sink {
+	return &streamSink{out: out}
+}
+
+func (s *streamSink) Write(status *client.SolveStatus) {
+	for _, v := range status.Vertexes {
+		if v.Completed != nil {
+			io.WriteString(s.out, v.Name+"\n")
+		}
+	}
+	for _, l := range status.Logs {
+		s.out.Write(l.Data)
+	}
+}
+
+func (s *streamSink) Close() {}
@@ -0,0 +1,36 @@
+package progress
+
+import (
+	"time"
+
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/moby/buildkit/client"
+	digest "github.com/opencontainers/go-digest"
+)
+
+// FromPullMessage adapts a docker image-pull JSONMessage into a
+// client.SolveStatus vertex.
+func FromPullMessage(msg jsonmessage.JSONMessage) *client.SolveStatus {
+	now := time.Now()
+	vertex := &client.Vertex{
+		Digest: digest.FromString(msg.ID),
+		Name:   msg.Status,
+	}
+	if msg.Progress == nil || msg.Status == "Pull complete" || msg.Status == "Already exists" {
+		vertex.Completed = &now
+	} else {
+		vertex.Started = &now
+	}
+
+	status := &client.SolveStatus{Vertexes: []*client.Vertex{vertex}}
+	if msg.Progress != nil {
+		status.Statuses = append(status.Statuses, &client.VertexStatus{
+			ID:        msg.ID,
+			Name:      msg.Status,
+			Current:   msg.Progress.Current,
+			Total:     msg.Progress.Total,
+			Timestamp: now,
+		})
+	}
+	return status
+}
@@ -1,17 +1,18 @@
 package state
 
 import (
-	"os"
+	"encoding/json"
+	"io"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/client"
 	"github.com/docker/docker/pkg/jsonmessage"
-	"github.com/docker/docker/pkg/term"
 	"github.com/oclaussen/dodo/config"
+	"github.com/oclaussen/dodo/pkg/progress"
 	"golang.org/x/net/context"
 )
 
-func pullImage(ctx context.Context, client *client.Client, config *config.BackdropConfig) (string, error) {
+func pullImage(ctx context.Context, client *client.Client, config *config.BackdropConfig, sink progress.Sink) (string, error) {
 	if !config.Pull {
 		if image := useExistingImage(ctx, client, config); image != "" {
 			return config.Image, nil
@@ -28,11 +29,18 @@ func pullImage(ctx context.Context, client *client.Client, config *config.Backdr
 	}
 	defer response.Close()
 
-	outFd, isTerminal := term.GetFdInfo(os.Stdout)
-	err = jsonmessage.DisplayJSONMessagesStream(response, os.Stdout, outFd, isTerminal, nil)
-	if err != nil {
-		return "", err
+	decoder := json.NewDecoder(response)
+	for {
+		var msg jsonmessage.JSONMessage
+		if err := decoder.Decode(&msg); err != nil {
+			if err == io.EOF {
+				return config.Image, nil
+			}
+			return "", err
+		}
+		if msg.Error != nil {
+			return "", msg.Error
+		}
+		sink.Write(progress.FromPullMessage(msg))
 	}
-
-	return config.Image, nil
 }